@@ -1,31 +1,40 @@
 package appengine
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 
+	gbq "cloud.google.com/go/bigquery"
+	"github.com/googlegenomics/beacon-go/backend"
+	bqbackend "github.com/googlegenomics/beacon-go/backend/bigquery"
+	"github.com/googlegenomics/beacon-go/backend/vcf"
 	"github.com/googlegenomics/beacon-go/beacon"
+	"github.com/googlegenomics/beacon-go/internal/privacy"
 )
 
 const (
-	project  = "GOOGLE_CLOUD_PROJECT"
-	bqTable  = "GOOGLE_BIGQUERY_TABLE"
-	authMode = "AUTHENTICATION_MODE"
+	project        = "GOOGLE_CLOUD_PROJECT"
+	bqTable        = "GOOGLE_BIGQUERY_TABLE"
+	beaconID       = "BEACON_ID"
+	beaconName     = "BEACON_NAME"
+	organization   = "BEACON_ORGANIZATION"
+	backendType    = "BEACON_BACKEND"
+	vcfPath        = "BEACON_VCF_PATH"
+	vcfIndexPath   = "BEACON_VCF_INDEX_PATH"
+	privacyEpsilon = "BEACON_PRIVACY_EPSILON"
+	privacyBudget  = "BEACON_PRIVACY_BUDGET"
 )
 
 func init() {
 	server := beacon.Server{
-		ProjectID: os.Getenv(project),
-		TableID:   os.Getenv(bqTable),
-		AuthMode:  serverAuthMode(),
-	}
-
-	if server.ProjectID == "" {
-		panic(fmt.Sprintf("environment variable %s must be specified", project))
-	}
-	if server.TableID == "" {
-		panic(fmt.Sprintf("environment variable %s must be specified", bqTable))
+		ID:           os.Getenv(beaconID),
+		Name:         os.Getenv(beaconName),
+		Organization: os.Getenv(organization),
+		Datasets:     []beacon.Dataset{{ID: "default", Backend: newBackend()}},
+		Privacy:      newPrivacyConfig(),
 	}
 
 	mux := http.NewServeMux()
@@ -34,13 +43,78 @@ func init() {
 	http.HandleFunc("/", mux.ServeHTTP)
 }
 
-func serverAuthMode() beacon.AuthenticationMode {
-	switch os.Getenv(authMode) {
-	case "", "service":
-		return beacon.ServiceAuth
-	case "user":
-		return beacon.UserAuth
+// newPrivacyConfig builds the differential-privacy layer from BEACON_PRIVACY_EPSILON and
+// BEACON_PRIVACY_BUDGET, or returns nil (disabling the layer) if BEACON_PRIVACY_BUDGET is unset.
+func newPrivacyConfig() *privacy.Config {
+	budgetEnv := os.Getenv(privacyBudget)
+	if budgetEnv == "" {
+		return nil
+	}
+	budget, err := strconv.Atoi(budgetEnv)
+	if err != nil {
+		panic(fmt.Sprintf("parsing %s: %v", privacyBudget, err))
+	}
+	epsilon, err := strconv.ParseFloat(os.Getenv(privacyEpsilon), 64)
+	if err != nil {
+		panic(fmt.Sprintf("parsing %s: %v", privacyEpsilon, err))
+	}
+
+	return &privacy.Config{
+		Epsilon: epsilon,
+		Budget:  budget,
+		Store:   privacy.NewMemoryStore(100000),
+		Clock:   privacy.SystemClock{},
+		Rand:    privacy.SecureRand{},
+	}
+}
+
+// newBackend constructs the backend.Backend selected by the BEACON_BACKEND environment variable,
+// defaulting to BigQuery for compatibility with existing deployments.
+func newBackend() backend.Backend {
+	switch os.Getenv(backendType) {
+	case "", "bigquery":
+		return newBigQueryBackend()
+	case "vcf":
+		return newVCFBackend()
 	default:
-		panic(fmt.Sprintf("invalid value for %s, specify service or user", authMode))
+		panic(fmt.Sprintf("invalid value for %s, specify bigquery or vcf", backendType))
+	}
+}
+
+func newBigQueryBackend() backend.Backend {
+	projectID := os.Getenv(project)
+	if projectID == "" {
+		panic(fmt.Sprintf("environment variable %s must be specified", project))
+	}
+	tableID := os.Getenv(bqTable)
+	if tableID == "" {
+		panic(fmt.Sprintf("environment variable %s must be specified", bqTable))
+	}
+
+	client, err := gbq.NewClient(context.Background(), projectID)
+	if err != nil {
+		panic(fmt.Sprintf("creating bigquery client: %v", err))
+	}
+	return bqbackend.New(client, tableID)
+}
+
+func newVCFBackend() backend.Backend {
+	path := os.Getenv(vcfPath)
+	if path == "" {
+		panic(fmt.Sprintf("environment variable %s must be specified", vcfPath))
+	}
+	indexPath := os.Getenv(vcfIndexPath)
+	if indexPath == "" {
+		panic(fmt.Sprintf("environment variable %s must be specified", vcfIndexPath))
+	}
+
+	source, err := vcf.Open(path)
+	if err != nil {
+		panic(fmt.Sprintf("opening %s: %v", path, err))
+	}
+	index, err := vcf.Open(indexPath)
+	if err != nil {
+		panic(fmt.Sprintf("opening %s: %v", indexPath, err))
 	}
+	return &vcf.Backend{VCF: source, Index: index}
 }
@@ -0,0 +1,29 @@
+/*
+ * Copyright (C) 2018 Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+// Package backend defines the interface a beacon.Dataset uses to look variants up in whatever
+// storage engine holds them.
+package backend
+
+import (
+	"context"
+
+	"github.com/googlegenomics/beacon-go/internal/variants"
+)
+
+// Backend performs variants.Query lookups against a variant data source.
+type Backend interface {
+	// Lookup executes q and returns aggregate statistics for any matching variants.
+	Lookup(ctx context.Context, q *variants.Query) (*variants.Result, error)
+}
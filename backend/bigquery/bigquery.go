@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2018 Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+// Package bigquery implements a backend.Backend that looks variants up in a BigQuery table.
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gbq "cloud.google.com/go/bigquery"
+	"github.com/googlegenomics/beacon-go/internal/variants"
+)
+
+// Backend looks variants up in a BigQuery table.
+type Backend struct {
+	client  *gbq.Client
+	tableID string
+}
+
+// New returns a Backend that queries tableID (in the form
+// bigquery-project.dataset.table) using client.
+func New(client *gbq.Client, tableID string) *Backend {
+	return &Backend{client: client, tableID: tableID}
+}
+
+// Lookup implements backend.Backend.
+func (b *Backend) Lookup(ctx context.Context, q *variants.Query) (*variants.Result, error) {
+	where, params := whereClause(q)
+	sql := aggregateSQL(b.tableID, where)
+
+	query := b.client.Query(sql)
+	query.Parameters = params
+
+	it, err := query.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("querying database: %v", err)
+	}
+
+	var row struct {
+		VariantCount int64   `bigquery:"variant_count"`
+		CallCount    int64   `bigquery:"call_count"`
+		SampleCount  int64   `bigquery:"sample_count"`
+		Frequency    float64 `bigquery:"frequency"`
+	}
+	if err := it.Next(&row); err != nil {
+		return nil, fmt.Errorf("reading query result: %v", err)
+	}
+	return &variants.Result{
+		VariantCount: row.VariantCount,
+		CallCount:    row.CallCount,
+		SampleCount:  row.SampleCount,
+		Frequency:    row.Frequency,
+	}, nil
+}
+
+// aggregateSQL builds the aggregate query run against tableID, filtered by where. The aggregates
+// are wrapped in IFNULL because a query that matches zero rows (the common "allele absent" case)
+// makes SUM and SAFE_DIVIDE evaluate to NULL, which the client library refuses to scan into the
+// non-nullable result fields.
+func aggregateSQL(tableID, where string) string {
+	return fmt.Sprintf(`
+		SELECT
+			COUNT(*) as variant_count,
+			IFNULL(SUM(call_count), 0) as call_count,
+			IFNULL(SUM(sample_count), 0) as sample_count,
+			IFNULL(SAFE_DIVIDE(SUM(allele_count), SUM(allele_number)), 0) as frequency
+		FROM %s as v
+		WHERE %s`,
+		fmt.Sprintf("`%s`", tableID),
+		where,
+	)
+}
+
+// whereClause builds a parameterized WHERE clause for q, returning the clause text
+// (referencing named parameters) alongside the bound parameter values. Values are never
+// interpolated directly into the SQL.
+func whereClause(q *variants.Query) (string, []gbq.QueryParameter) {
+	var clauses []string
+	var params []gbq.QueryParameter
+	add := func(clause string) {
+		clauses = append(clauses, clause)
+	}
+	param := func(name string, value interface{}) string {
+		params = append(params, gbq.QueryParameter{Name: name, Value: value})
+		return "@" + name
+	}
+	simpleClause := func(dbColumn, paramName string, value interface{}) {
+		switch value := value.(type) {
+		case string:
+			if value != "" {
+				add(fmt.Sprintf("%s=%s", dbColumn, param(paramName, value)))
+			}
+		case *int64:
+			if value != nil {
+				add(fmt.Sprintf("%s=%s", dbColumn, param(paramName, *value)))
+			}
+		}
+	}
+	simpleClause("reference_name", "refName", q.RefName)
+	simpleClause("reference_bases", "allele", q.Allele)
+	simpleClause("alternate_bases", "alternateBases", q.AlternateBases)
+	simpleClause("start_position", "start", q.Start)
+	simpleClause("end_position", "end", q.End)
+
+	if q.StartMin != nil {
+		add(fmt.Sprintf("%s <= v.start_position", param("startMin", *q.StartMin)))
+	}
+	if q.StartMax != nil {
+		add(fmt.Sprintf("v.start_position <= %s", param("startMax", *q.StartMax)))
+	}
+	if q.EndMin != nil {
+		add(fmt.Sprintf("%s <= v.end_position", param("endMin", *q.EndMin)))
+	}
+	if q.EndMax != nil {
+		add(fmt.Sprintf("v.end_position <= %s", param("endMax", *q.EndMax)))
+	}
+
+	// The table has no explicit variant-type column, so variant classes are recognized with
+	// heuristics over reference_bases/alternate_bases: a SNP has equal-length alleles, an
+	// insertion/deletion is recognized by the direction of the length mismatch, and the
+	// remaining structural types are recognized by their symbolic ALT notation (e.g. "<DUP>")
+	// together with the same reference/alternate length mismatch.
+	switch q.VariantType {
+	case "":
+	case variants.SNP:
+		add("LENGTH(v.reference_bases) = LENGTH(v.alternate_bases)")
+	case variants.Insertion:
+		add("LENGTH(v.alternate_bases) > LENGTH(v.reference_bases)")
+	case variants.Deletion:
+		add("LENGTH(v.alternate_bases) < LENGTH(v.reference_bases)")
+	case variants.Duplication, variants.CopyNumberVariant, variants.Breakend:
+		add(fmt.Sprintf("v.alternate_bases LIKE %s AND LENGTH(v.reference_bases) != LENGTH(v.alternate_bases)",
+			param("variantTypeSymbol", fmt.Sprintf("<%s%%", q.VariantType))))
+	}
+
+	return strings.Join(clauses, " AND "), params
+}
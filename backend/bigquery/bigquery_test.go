@@ -0,0 +1,108 @@
+package bigquery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/googlegenomics/beacon-go/internal/variants"
+)
+
+func TestWhereClauseParameterizesUserInput(t *testing.T) {
+	pathological := []string{
+		`'; DROP TABLE variants; --`,
+		`x' OR '1'='1`,
+		`A' UNION SELECT * FROM secrets -- `,
+	}
+	for _, value := range pathological {
+		q := &variants.Query{RefName: value, Allele: value}
+		clause, params := whereClause(q)
+
+		if strings.Contains(clause, value) {
+			t.Errorf("whereClause() with RefName/Allele %q produced clause %q; want the raw value kept out of the SQL text", value, clause)
+		}
+		if len(params) != 2 {
+			t.Fatalf("whereClause() with RefName/Allele %q produced %d parameters, want 2", value, len(params))
+		}
+		for _, p := range params {
+			if p.Value != value {
+				t.Errorf("parameter %s = %v, want %q", p.Name, p.Value, value)
+			}
+		}
+	}
+}
+
+func TestWhereClauseRangeBounds(t *testing.T) {
+	startMax := int64(100)
+	endMax := int64(200)
+	q := &variants.Query{RefName: "chr1", Allele: "A", StartMax: &startMax, EndMax: &endMax}
+	clause, params := whereClause(q)
+
+	if !strings.Contains(clause, "v.start_position <= @startMax") {
+		t.Errorf("whereClause() = %q, want a valid StartMax predicate", clause)
+	}
+	if !strings.Contains(clause, "v.end_position <= @endMax") {
+		t.Errorf("whereClause() = %q, want the EndMax predicate to bound v.end_position, not v.start_position", clause)
+	}
+
+	values := map[string]interface{}{}
+	for _, p := range params {
+		values[p.Name] = p.Value
+	}
+	if values["startMax"] != startMax {
+		t.Errorf("startMax parameter = %v, want %d", values["startMax"], startMax)
+	}
+	if values["endMax"] != endMax {
+		t.Errorf("endMax parameter = %v, want %d", values["endMax"], endMax)
+	}
+}
+
+func TestWhereClauseVariantTypeHeuristics(t *testing.T) {
+	tests := []struct {
+		variantType variants.VariantType
+		want        string
+	}{
+		{variants.SNP, "LENGTH(v.reference_bases) = LENGTH(v.alternate_bases)"},
+		{variants.Insertion, "LENGTH(v.alternate_bases) > LENGTH(v.reference_bases)"},
+		{variants.Deletion, "LENGTH(v.alternate_bases) < LENGTH(v.reference_bases)"},
+	}
+	for _, test := range tests {
+		q := &variants.Query{RefName: "chr1", VariantType: test.variantType}
+		clause, _ := whereClause(q)
+		if !strings.Contains(clause, test.want) {
+			t.Errorf("whereClause() with VariantType %s = %q, want it to contain %q", test.variantType, clause, test.want)
+		}
+	}
+
+	q := &variants.Query{RefName: "chr1", VariantType: variants.Duplication}
+	clause, params := whereClause(q)
+	if !strings.Contains(clause, "v.alternate_bases LIKE @variantTypeSymbol") ||
+		!strings.Contains(clause, "LENGTH(v.reference_bases) != LENGTH(v.alternate_bases)") {
+		t.Errorf("whereClause() with VariantType DUP = %q, want a symbolic ALT and length-mismatch predicate", clause)
+	}
+	found := false
+	for _, p := range params {
+		if p.Name == "variantTypeSymbol" {
+			found = true
+			if p.Value != "<DUP%" {
+				t.Errorf("variantTypeSymbol parameter = %v, want %q", p.Value, "<DUP%")
+			}
+		}
+	}
+	if !found {
+		t.Errorf("whereClause() with VariantType DUP params = %v, missing variantTypeSymbol parameter", params)
+	}
+}
+
+func TestAggregateSQLHandlesZeroMatchingRows(t *testing.T) {
+	sql := aggregateSQL("project.dataset.table", "v.reference_name=@refName")
+
+	for _, aggregate := range []string{
+		"IFNULL(SUM(call_count), 0)",
+		"IFNULL(SUM(sample_count), 0)",
+		"IFNULL(SAFE_DIVIDE(SUM(allele_count), SUM(allele_number)), 0)",
+	} {
+		if !strings.Contains(sql, aggregate) {
+			t.Errorf("aggregateSQL() = %q, want it to contain %q so a zero-row result scans as 0 instead of NULL", sql, aggregate)
+		}
+	}
+}
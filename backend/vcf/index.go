@@ -0,0 +1,217 @@
+/*
+ * Copyright (C) 2018 Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package vcf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// tabixMagic is the 4-byte magic that begins every .tbi index (see the tabix/SAM binning index
+// format: https://samtools.github.io/hts-specs/tabix.pdf).
+var tabixMagic = [4]byte{'T', 'B', 'I', 1}
+
+// chunk is a byte range in the bgzipped VCF, expressed as BGZF virtual file offsets: the high 48
+// bits are the compressed offset of the start of a block, the low 16 bits are the offset of the
+// decompressed byte within that block.
+type chunk struct {
+	begin, end uint64
+}
+
+// refIndex is one reference sequence's entry in a tabixIndex.
+type refIndex struct {
+	bins   map[uint32][]chunk
+	linear []uint64 // smallest virtual offset of any record overlapping each 16kbp interval
+}
+
+// tabixIndex is a parsed .tbi index, giving the byte ranges in a bgzipped VCF that may contain
+// records for a given reference name and position range.
+type tabixIndex struct {
+	refIDs map[string]int
+	refs   []refIndex
+}
+
+// parseIndex reads and parses a .tbi index. The index itself is BGZF/gzip compressed; since
+// indexes are small relative to the VCF data they describe, it is decompressed in full rather
+// than read block-by-block.
+func parseIndex(r io.Reader) (*tabixIndex, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip stream: %v", err)
+	}
+	defer gz.Close()
+
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing index: %v", err)
+	}
+	buf := bytes.NewReader(data)
+
+	var magic [4]byte
+	if err := binary.Read(buf, binary.LittleEndian, &magic); err != nil {
+		return nil, fmt.Errorf("reading magic: %v", err)
+	}
+	if magic != tabixMagic {
+		return nil, fmt.Errorf("unexpected magic %v, want a tabix (.tbi) index", magic)
+	}
+
+	var header struct {
+		NumRefs   int32
+		Format    int32
+		ColSeq    int32
+		ColBeg    int32
+		ColEnd    int32
+		Meta      int32
+		Skip      int32
+		NameBytes int32
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+
+	names := make([]byte, header.NameBytes)
+	if _, err := io.ReadFull(buf, names); err != nil {
+		return nil, fmt.Errorf("reading reference names: %v", err)
+	}
+
+	idx := &tabixIndex{refIDs: map[string]int{}}
+	for i, name := range bytes.Split(bytes.TrimRight(names, "\x00"), []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		idx.refIDs[string(name)] = i
+	}
+
+	idx.refs = make([]refIndex, header.NumRefs)
+	for i := range idx.refs {
+		ref := refIndex{bins: map[uint32][]chunk{}}
+
+		var numBins int32
+		if err := binary.Read(buf, binary.LittleEndian, &numBins); err != nil {
+			return nil, fmt.Errorf("reading bin count for reference %d: %v", i, err)
+		}
+		for b := int32(0); b < numBins; b++ {
+			var bin uint32
+			var numChunks int32
+			if err := binary.Read(buf, binary.LittleEndian, &bin); err != nil {
+				return nil, fmt.Errorf("reading bin number for reference %d: %v", i, err)
+			}
+			if err := binary.Read(buf, binary.LittleEndian, &numChunks); err != nil {
+				return nil, fmt.Errorf("reading chunk count for reference %d bin %d: %v", i, bin, err)
+			}
+			chunks := make([]chunk, numChunks)
+			for c := range chunks {
+				if err := binary.Read(buf, binary.LittleEndian, &chunks[c].begin); err != nil {
+					return nil, fmt.Errorf("reading chunk begin for reference %d bin %d: %v", i, bin, err)
+				}
+				if err := binary.Read(buf, binary.LittleEndian, &chunks[c].end); err != nil {
+					return nil, fmt.Errorf("reading chunk end for reference %d bin %d: %v", i, bin, err)
+				}
+			}
+			ref.bins[bin] = chunks
+		}
+
+		var numIntervals int32
+		if err := binary.Read(buf, binary.LittleEndian, &numIntervals); err != nil {
+			return nil, fmt.Errorf("reading linear index interval count for reference %d: %v", i, err)
+		}
+		ref.linear = make([]uint64, numIntervals)
+		if err := binary.Read(buf, binary.LittleEndian, &ref.linear); err != nil {
+			return nil, fmt.Errorf("reading linear index for reference %d: %v", i, err)
+		}
+
+		idx.refs[i] = ref
+	}
+
+	return idx, nil
+}
+
+// tabixLinearWindow is the size, in base pairs, of each linear index interval (1 << 14).
+const tabixLinearWindow = 1 << 14
+
+// chunksFor returns the chunks that may contain records for refName overlapping the half-open
+// interval [start, end), and whether refName appears in the index at all.
+func (idx *tabixIndex) chunksFor(refName string, start, end int64) ([]chunk, bool) {
+	refID, ok := idx.refIDs[refName]
+	if !ok || refID >= len(idx.refs) {
+		return nil, false
+	}
+	ref := idx.refs[refID]
+
+	var minOffset uint64
+	if i := start / tabixLinearWindow; i >= 0 && int(i) < len(ref.linear) {
+		minOffset = ref.linear[i]
+	}
+
+	var chunks []chunk
+	for _, bin := range reg2bins(start, end) {
+		for _, c := range ref.bins[bin] {
+			if c.end > minOffset {
+				chunks = append(chunks, c)
+			}
+		}
+	}
+	return mergeChunks(chunks), true
+}
+
+// reg2bins returns the tabix/BAI bins that may contain records overlapping the half-open,
+// 0-based interval [beg, end), using the standard binning scheme (see the tabix/SAM spec).
+func reg2bins(beg, end int64) []uint32 {
+	if end <= beg {
+		end = beg + 1
+	}
+	end--
+
+	bins := []uint32{0}
+	for _, level := range []struct{ shift, offset int64 }{
+		{26, 1}, {23, 9}, {20, 73}, {17, 585}, {14, 4681},
+	} {
+		lo := level.offset + (beg >> level.shift)
+		hi := level.offset + (end >> level.shift)
+		for k := lo; k <= hi; k++ {
+			bins = append(bins, uint32(k))
+		}
+	}
+	return bins
+}
+
+// mergeChunks sorts and coalesces overlapping or adjacent chunks so that each overlapping group
+// is read as a single range.
+func mergeChunks(chunks []chunk) []chunk {
+	if len(chunks) == 0 {
+		return nil
+	}
+	for i := 1; i < len(chunks); i++ {
+		for j := i; j > 0 && chunks[j-1].begin > chunks[j].begin; j-- {
+			chunks[j-1], chunks[j] = chunks[j], chunks[j-1]
+		}
+	}
+	merged := chunks[:1]
+	for _, c := range chunks[1:] {
+		last := &merged[len(merged)-1]
+		if c.begin <= last.end {
+			if c.end > last.end {
+				last.end = c.end
+			}
+			continue
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
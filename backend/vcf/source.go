@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2018 Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package vcf
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Source is the bytes backing a .vcf.gz or .tbi file. It is satisfied by both a local file and a
+// remote object read over HTTP range requests, so Backend never has to download a whole file to
+// answer one query.
+type Source interface {
+	io.ReaderAt
+	// Size returns the total size of the source in bytes.
+	Size() (int64, error)
+}
+
+// Open returns a Source for path, which may be a local filesystem path or an http(s) URL (for
+// example a GCS object's public download URL) to be read with HTTP range requests.
+func Open(path string) (Source, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return &HTTPSource{URL: path}, nil
+	}
+	return OpenFile(path)
+}
+
+// FileSource reads a Source from the local filesystem.
+type FileSource struct {
+	*os.File
+}
+
+// OpenFile opens path on the local filesystem as a Source.
+func OpenFile(path string) (*FileSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSource{f}, nil
+}
+
+// Size implements Source.
+func (f *FileSource) Size() (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// HTTPSource reads a Source from a remote object using HTTP range requests.
+type HTTPSource struct {
+	// URL is the address of the remote object, e.g. a GCS object's download URL.
+	URL string
+	// Client is used to issue requests. http.DefaultClient is used if nil.
+	Client *http.Client
+}
+
+func (s *HTTPSource) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+// ReadAt implements io.ReaderAt by issuing an HTTP Range request for the requested bytes.
+func (s *HTTPSource) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("range request for %s returned status %s, want %d", s.URL, resp.Status, http.StatusPartialContent)
+	}
+	return io.ReadFull(resp.Body, p)
+}
+
+// Size implements Source by issuing a HEAD request for the remote object's Content-Length.
+func (s *HTTPSource) Size() (int64, error) {
+	resp, err := s.client().Head(s.URL)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("%s did not report a Content-Length", s.URL)
+	}
+	return resp.ContentLength, nil
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright (C) 2018 Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+// Package vcf implements a backend.Backend that looks variants up directly in a tabix-indexed,
+// bgzipped VCF file (a .vcf.gz with an accompanying .tbi index), without requiring BigQuery. The
+// file may be read from local disk or, via Source, over HTTP range requests, so small beacons can
+// run without a live cloud project.
+package vcf
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/googlegenomics/beacon-go/internal/variants"
+)
+
+// maxBGZFBlockSize is the largest compressed size of a single BGZF block, used to pad how far
+// past a chunk's end offset Backend reads to be sure of capturing that block in full.
+const maxBGZFBlockSize = 65536
+
+// Backend looks variants up directly in a tabix-indexed, bgzipped VCF file.
+type Backend struct {
+	// VCF is the bgzipped VCF data (a .vcf.gz file).
+	VCF Source
+	// Index is the tabix index for VCF (a .tbi file).
+	Index Source
+
+	once sync.Once
+	idx  *tabixIndex
+	err  error
+
+	headerOnce  sync.Once
+	sampleCount int64
+	headerErr   error
+}
+
+func (b *Backend) index() (*tabixIndex, error) {
+	b.once.Do(func() {
+		size, err := b.Index.Size()
+		if err != nil {
+			b.err = fmt.Errorf("reading tabix index size: %v", err)
+			return
+		}
+		b.idx, b.err = parseIndex(io.NewSectionReader(b.Index, 0, size))
+		if b.err != nil {
+			b.err = fmt.Errorf("parsing tabix index: %v", b.err)
+		}
+	})
+	return b.idx, b.err
+}
+
+// sampleCountFromHeader returns the number of samples declared in the VCF's #CHROM header line
+// (the columns after FORMAT), reading and caching it from the start of the file the first time
+// it's needed. A VCF with no samples, or a malformed header, yields 0.
+func (b *Backend) sampleCountFromHeader() (int64, error) {
+	b.headerOnce.Do(func() {
+		size, err := b.VCF.Size()
+		if err != nil {
+			b.headerErr = fmt.Errorf("reading VCF size: %v", err)
+			return
+		}
+		gz, err := gzip.NewReader(io.NewSectionReader(b.VCF, 0, size))
+		if err != nil {
+			b.headerErr = fmt.Errorf("opening VCF header: %v", err)
+			return
+		}
+		defer gz.Close()
+
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "#") {
+				break
+			}
+			if strings.HasPrefix(line, "#CHROM") {
+				b.sampleCount = parseSampleCount(line)
+				return
+			}
+		}
+	})
+	return b.sampleCount, b.headerErr
+}
+
+// Lookup implements backend.Backend.
+func (b *Backend) Lookup(ctx context.Context, q *variants.Query) (*variants.Result, error) {
+	idx, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := regionBounds(q)
+	chunks, ok := idx.chunksFor(q.RefName, start, end)
+	if !ok {
+		return &variants.Result{}, nil
+	}
+
+	size, err := b.VCF.Size()
+	if err != nil {
+		return nil, fmt.Errorf("reading VCF size: %v", err)
+	}
+
+	var result variants.Result
+	var alleleCount, alleleNumber int64
+	// The padded reads of neighbouring chunks can overlap, so the same record may be scanned more
+	// than once; seen makes sure each record is counted once.
+	seen := map[string]bool{}
+	for _, c := range chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		decompressed, err := decompressChunk(b.VCF, c, size)
+		if err != nil {
+			return nil, fmt.Errorf("reading VCF data: %v", err)
+		}
+
+		var lines []string
+		scanner := bufio.NewScanner(decompressed)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		// A truncated final BGZF block at the padded read boundary surfaces here as a
+		// compress/gzip error, after the scanner has returned the cut-off last line; that line is
+		// dropped, so only the lines fully read by that point are accounted for.
+		if err := scanner.Err(); err != nil && err != bufio.ErrTooLong && len(lines) > 0 {
+			lines = lines[:len(lines)-1]
+		}
+		for _, line := range lines {
+			if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+				continue
+			}
+			seen[line] = true
+			rec, ok := parseRecord(line)
+			if !ok {
+				continue
+			}
+			if !q.MatchesVariant(rec.chrom, rec.ref, rec.alt, rec.start, rec.end) {
+				continue
+			}
+			result.VariantCount++
+			result.CallCount += rec.callCount
+			alleleCount += rec.alleleCount
+			alleleNumber += rec.alleleNumber
+		}
+	}
+	if alleleNumber > 0 {
+		result.Frequency = float64(alleleCount) / float64(alleleNumber)
+	}
+	if result.VariantCount > 0 {
+		sampleCount, err := b.sampleCountFromHeader()
+		if err != nil {
+			return nil, err
+		}
+		result.SampleCount = sampleCount
+	}
+	return &result, nil
+}
+
+// regionBounds derives the half-open [start, end) region a Query covers, for pruning the tabix
+// index down to the chunks that might contain a match. The exact match semantics (including
+// single-position and exact-coordinate queries) are applied afterwards by
+// variants.Query.MatchesVariant.
+func regionBounds(q *variants.Query) (int64, int64) {
+	switch {
+	case q.StartMin != nil || q.EndMax != nil:
+		var start, end int64
+		if q.StartMin != nil {
+			start = *q.StartMin
+		}
+		end = start + 1
+		if q.EndMax != nil {
+			end = *q.EndMax
+		}
+		return start, end
+	case q.Start != nil:
+		start := *q.Start
+		end := start + 1
+		if q.End != nil {
+			end = *q.End
+		}
+		return start, end
+	default:
+		return 0, 1 << 62
+	}
+}
+
+// decompressChunk returns a reader over the decompressed bytes of the bgzipped source starting
+// at chunk c's begin virtual offset. It reads generously past c's end offset (by up to a full
+// BGZF block) to guarantee that block is read in full; callers stop relying on the reader once
+// they've consumed the records they need or hit an error.
+func decompressChunk(source Source, c chunk, size int64) (io.Reader, error) {
+	compressedBegin := int64(c.begin >> 16)
+	withinBlockBegin := int64(c.begin & 0xffff)
+	compressedEnd := int64(c.end >> 16)
+
+	length := compressedEnd - compressedBegin + maxBGZFBlockSize
+	if compressedBegin+length > size {
+		length = size - compressedBegin
+	}
+	if length <= 0 {
+		return nil, fmt.Errorf("chunk %+v is out of range for a source of size %d", c, size)
+	}
+
+	gz, err := gzip.NewReader(io.NewSectionReader(source, compressedBegin, length))
+	if err != nil {
+		return nil, fmt.Errorf("opening bgzf block at offset %d: %v", compressedBegin, err)
+	}
+	if withinBlockBegin > 0 {
+		if _, err := io.CopyN(ioutil.Discard, gz, withinBlockBegin); err != nil {
+			return nil, fmt.Errorf("seeking to within-block offset %d: %v", withinBlockBegin, err)
+		}
+	}
+	return gz, nil
+}
+
+// parseSampleCount returns the number of samples declared on a VCF "#CHROM" header line, i.e.
+// the columns after FORMAT. It returns 0 if the line has no sample columns.
+func parseSampleCount(headerLine string) int64 {
+	fields := strings.Split(headerLine, "\t")
+	if len(fields) <= 9 {
+		return 0
+	}
+	return int64(len(fields) - 9)
+}
+
+// record is the subset of a VCF data line Backend needs to evaluate a Query against it.
+type record struct {
+	chrom                     string
+	start, end                int64 // 0-based, half-open, matching variants.Query's coordinate convention
+	ref, alt                  string
+	callCount                 int64
+	alleleCount, alleleNumber int64
+}
+
+// parseRecord parses a single VCF data line (CHROM, POS, ID, REF, ALT, QUAL, FILTER, INFO, and
+// optionally FORMAT plus sample columns).
+func parseRecord(line string) (record, bool) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 8 {
+		return record{}, false
+	}
+
+	pos, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return record{}, false
+	}
+
+	rec := record{
+		chrom: fields[0],
+		ref:   fields[3],
+		alt:   fields[4],
+	}
+	rec.start = pos - 1 // VCF POS is 1-based; variants.Query coordinates are 0-based.
+	rec.end = rec.start + int64(len(rec.ref))
+
+	for _, entry := range strings.Split(fields[7], ";") {
+		key, value, found := strings.Cut(entry, "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "AC":
+			if v, err := strconv.ParseInt(strings.SplitN(value, ",", 2)[0], 10, 64); err == nil {
+				rec.alleleCount = v
+			}
+		case "AN":
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+				rec.alleleNumber = v
+			}
+		}
+	}
+	if len(fields) > 9 {
+		rec.callCount = int64(len(fields) - 9)
+	}
+
+	return rec, true
+}
@@ -0,0 +1,76 @@
+package vcf
+
+import "testing"
+
+func TestParseRecord(t *testing.T) {
+	line := "chr1\t101\t.\tA\tG\t50\tPASS\tAC=3;AN=10\tGT\t0/1\t0/0"
+	rec, ok := parseRecord(line)
+	if !ok {
+		t.Fatalf("parseRecord(%q) failed", line)
+	}
+	if rec.chrom != "chr1" || rec.start != 100 || rec.end != 101 {
+		t.Errorf("parseRecord(%q) = %+v, want chrom=chr1 start=100 end=101", line, rec)
+	}
+	if rec.ref != "A" || rec.alt != "G" {
+		t.Errorf("parseRecord(%q) ref/alt = %q/%q, want A/G", line, rec.ref, rec.alt)
+	}
+	if rec.alleleCount != 3 || rec.alleleNumber != 10 {
+		t.Errorf("parseRecord(%q) AC/AN = %d/%d, want 3/10", line, rec.alleleCount, rec.alleleNumber)
+	}
+	if rec.callCount != 2 {
+		t.Errorf("parseRecord(%q) callCount = %d, want 2", line, rec.callCount)
+	}
+}
+
+func TestParseSampleCount(t *testing.T) {
+	line := "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tNA001\tNA002\tNA003"
+	if got := parseSampleCount(line); got != 3 {
+		t.Errorf("parseSampleCount(%q) = %d, want 3", line, got)
+	}
+}
+
+func TestParseSampleCountWithNoSamples(t *testing.T) {
+	line := "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO"
+	if got := parseSampleCount(line); got != 0 {
+		t.Errorf("parseSampleCount(%q) = %d, want 0", line, got)
+	}
+}
+
+func TestParseRecordRejectsMalformedLines(t *testing.T) {
+	if _, ok := parseRecord("too\tfew\tcolumns"); ok {
+		t.Error("parseRecord() with too few columns succeeded, want failure")
+	}
+	if _, ok := parseRecord("chr1\tnotanumber\t.\tA\tG\t.\t.\t."); ok {
+		t.Error("parseRecord() with a non-numeric POS succeeded, want failure")
+	}
+}
+
+func TestMergeChunks(t *testing.T) {
+	got := mergeChunks([]chunk{
+		{begin: 100, end: 200},
+		{begin: 150, end: 250},
+		{begin: 300, end: 400},
+	})
+	want := []chunk{{begin: 100, end: 250}, {begin: 300, end: 400}}
+	if len(got) != len(want) {
+		t.Fatalf("mergeChunks() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mergeChunks()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReg2BinsIncludesBinZero(t *testing.T) {
+	bins := reg2bins(0, 100)
+	found := false
+	for _, b := range bins {
+		if b == 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("reg2bins(0, 100) = %v, want it to include the top-level bin 0", bins)
+	}
+}
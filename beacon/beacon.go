@@ -16,51 +16,60 @@
 package beacon
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"html/template"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 
-	"cloud.google.com/go/bigquery"
+	"github.com/googlegenomics/beacon-go/backend"
+	"github.com/googlegenomics/beacon-go/internal/privacy"
 	"github.com/googlegenomics/beacon-go/internal/variants"
-	"golang.org/x/oauth2"
-	"google.golang.org/api/option"
-	"google.golang.org/appengine"
 )
 
-const beaconAPIVersion = "v0.0.1"
+const beaconAPIVersion = "v1.0.0"
 
 var (
 	aboutTemplate = template.Must(template.ParseFiles("about.xml"))
 )
 
-// AuthenticationMode defines what authentication credentials the server uses to connect to
-// BigQuery.
-type AuthenticationMode uint
-
-const (
-	// ServiceAuth will configure the server to use its service account credentials to access the
-	// BigQuery datasets.
-	ServiceAuth AuthenticationMode = iota
-	// UserAuth will configure the server to use the authentication header provided in the request to
-	// access the BigQuery datasets.
-	UserAuth
-)
+// Dataset describes a single allele collection that this beacon can query, along with the
+// metadata the Beacon API reports about it.
+type Dataset struct {
+	// ID uniquely identifies the dataset within this beacon.
+	ID string
+	// Description describes the dataset's contents.
+	Description string
+	// Backend looks variants up in this dataset's underlying storage.
+	Backend backend.Backend
+	// VariantCount is the total number of variants in the dataset.
+	VariantCount int64
+	// SampleCount is the total number of samples in the dataset.
+	SampleCount int64
+	// CreateDateTime is when the dataset was created, in RFC 3339 format.
+	CreateDateTime string
+}
 
 // Server provides handlers for Beacon API requests.
 type Server struct {
-	// ProjectID is the GCloud project ID.
-	ProjectID string
-	// TableID is the ID of the allele BigQuery table to query.
-	// Must be provided in the following format: bigquery-project.dataset.table.
-	TableID string
-	// AuthMode determines the authentication provider for the BigQuery client.
-	AuthMode AuthenticationMode
+	// ID uniquely identifies this beacon.
+	ID string
+	// Name is a human readable name for this beacon.
+	Name string
+	// Organization identifies the organization that operates this beacon.
+	Organization string
+	// Datasets are the datasets this beacon can query.
+	Datasets []Dataset
+	// Privacy, if set, randomizes the exists response under a per-client query budget to resist
+	// membership-inference attacks. A nil Privacy disables the mechanism.
+	Privacy *privacy.Config
 }
 
 // Export registers the beacon API endpoint with mux.
@@ -75,14 +84,114 @@ func (api *Server) About(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("HTTP method %s not supported", r.Method), http.StatusBadRequest)
 		return
 	}
-	w.Header().Set("Content-Type", "application/xml")
-	aboutTemplate.Execute(w, map[string]string{
-		"APIVersion": beaconAPIVersion,
-		"TableID":    api.TableID,
-	})
+
+	if prefersXML(r) {
+		var legacyDatasetID string
+		if len(api.Datasets) > 0 {
+			legacyDatasetID = api.Datasets[0].ID
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		aboutTemplate.Execute(w, map[string]string{
+			"APIVersion": beaconAPIVersion,
+			"TableID":    legacyDatasetID,
+		})
+		return
+	}
+
+	writeJSON(w, api.info())
+}
+
+// beaconDatasetInfo is the JSON representation of a Dataset in the Beacon object returned by
+// About.
+type beaconDatasetInfo struct {
+	ID             string `json:"id"`
+	Description    string `json:"description,omitempty"`
+	VariantCount   int64  `json:"variantCount"`
+	SampleCount    int64  `json:"sampleCount"`
+	CreateDateTime string `json:"createDateTime,omitempty"`
+}
+
+// beaconInfo is the JSON representation of the Beacon object returned by About.
+type beaconInfo struct {
+	ID           string              `json:"id"`
+	Name         string              `json:"name,omitempty"`
+	APIVersion   string              `json:"apiVersion"`
+	Organization string              `json:"organization,omitempty"`
+	Datasets     []beaconDatasetInfo `json:"datasets"`
+}
+
+func (api *Server) info() beaconInfo {
+	datasets := make([]beaconDatasetInfo, len(api.Datasets))
+	for i, dataset := range api.Datasets {
+		datasets[i] = beaconDatasetInfo{
+			ID:             dataset.ID,
+			Description:    dataset.Description,
+			VariantCount:   dataset.VariantCount,
+			SampleCount:    dataset.SampleCount,
+			CreateDateTime: dataset.CreateDateTime,
+		}
+	}
+	return beaconInfo{
+		ID:           api.ID,
+		Name:         api.Name,
+		APIVersion:   beaconAPIVersion,
+		Organization: api.Organization,
+		Datasets:     datasets,
+	}
+}
+
+// alleleRequest is the JSON echo of the request parameters in a BeaconAlleleResponse.
+type alleleRequest struct {
+	ReferenceName  string `json:"referenceName,omitempty"`
+	ReferenceBases string `json:"referenceBases,omitempty"`
+	AlternateBases string `json:"alternateBases,omitempty"`
+	AssemblyID     string `json:"assemblyId,omitempty"`
+	VariantType    string `json:"variantType,omitempty"`
+	Start          *int64 `json:"start,omitempty"`
+	End            *int64 `json:"end,omitempty"`
+	StartMin       *int64 `json:"startMin,omitempty"`
+	StartMax       *int64 `json:"startMax,omitempty"`
+	EndMin         *int64 `json:"endMin,omitempty"`
+	EndMax         *int64 `json:"endMax,omitempty"`
+}
+
+func newAlleleRequest(query *variants.Query) alleleRequest {
+	return alleleRequest{
+		ReferenceName:  query.RefName,
+		ReferenceBases: query.Allele,
+		AlternateBases: query.AlternateBases,
+		AssemblyID:     query.AssemblyID,
+		VariantType:    string(query.VariantType),
+		Start:          query.Start,
+		End:            query.End,
+		StartMin:       query.StartMin,
+		StartMax:       query.StartMax,
+		EndMin:         query.EndMin,
+		EndMax:         query.EndMax,
+	}
+}
+
+// datasetAlleleResponse is a single dataset's result within a BeaconAlleleResponse.
+type datasetAlleleResponse struct {
+	DatasetID    string  `json:"datasetId"`
+	Exists       bool    `json:"exists"`
+	VariantCount int64   `json:"variantCount,omitempty"`
+	CallCount    int64   `json:"callCount,omitempty"`
+	SampleCount  int64   `json:"sampleCount,omitempty"`
+	Frequency    float64 `json:"frequency,omitempty"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// beaconAlleleResponse is the JSON representation of the BeaconAlleleResponse returned by Query.
+type beaconAlleleResponse struct {
+	BeaconID               string                  `json:"beaconId"`
+	APIVersion             string                  `json:"apiVersion"`
+	Exists                 bool                    `json:"exists"`
+	AlleleRequest          alleleRequest           `json:"alleleRequest"`
+	DatasetAlleleResponses []datasetAlleleResponse `json:"datasetAlleleResponses"`
 }
 
-// Query retrieves whether the requested allele exists in the dataset.
+// Query retrieves whether the requested allele exists in the configured datasets.
 func (api *Server) Query(w http.ResponseWriter, r *http.Request) {
 	query, err := parseInput(r)
 	if err != nil {
@@ -95,18 +204,94 @@ func (api *Server) Query(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := api.newBQClient(r, api.ProjectID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("creating bigquery client: %v", err), http.StatusBadRequest)
-		return
+	responses := api.queryDatasets(r.Context(), query)
+
+	var exists bool
+	for _, response := range responses {
+		if response.Exists {
+			exists = true
+			break
+		}
 	}
 
-	exists, err := query.Execute(r.Context(), client, api.TableID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("computing result: %v", err), http.StatusInternalServerError)
+	if api.Privacy.Enabled() {
+		privatized, err := api.Privacy.Apply(clientIdentifier(r), exists)
+		if err != nil {
+			http.Error(w, "query budget exhausted", http.StatusTooManyRequests)
+			return
+		}
+		exists = privatized
+		// The per-dataset details above are exact; replace them with the privatized decision so
+		// differential privacy can't be bypassed by reading those fields instead of the top-level
+		// exists.
+		responses = privatizedResponses(responses, exists)
+	}
+
+	if prefersXML(r) {
+		writeLegacyXMLResponse(w, exists)
 		return
 	}
-	writeResponse(w, exists)
+	writeJSON(w, beaconAlleleResponse{
+		BeaconID:               api.ID,
+		APIVersion:             beaconAPIVersion,
+		Exists:                 exists,
+		AlleleRequest:          newAlleleRequest(query),
+		DatasetAlleleResponses: responses,
+	})
+}
+
+// queryDatasets fans Lookup out across all configured datasets in parallel, returning one
+// response per dataset in the same order the datasets were configured.
+func (api *Server) queryDatasets(ctx context.Context, query *variants.Query) []datasetAlleleResponse {
+	responses := make([]datasetAlleleResponse, len(api.Datasets))
+	var wg sync.WaitGroup
+	for i, dataset := range api.Datasets {
+		wg.Add(1)
+		go func(i int, dataset Dataset) {
+			defer wg.Done()
+			response := datasetAlleleResponse{DatasetID: dataset.ID}
+			result, err := dataset.Backend.Lookup(ctx, query)
+			if err != nil {
+				response.Error = err.Error()
+			} else {
+				response.Exists = result.VariantCount > 0
+				response.VariantCount = result.VariantCount
+				response.CallCount = result.CallCount
+				response.SampleCount = result.SampleCount
+				response.Frequency = result.Frequency
+			}
+			responses[i] = response
+		}(i, dataset)
+	}
+	wg.Wait()
+	return responses
+}
+
+// privatizedResponses replaces each dataset's exact result with the privatized top-level
+// decision, preserving only the dataset ID and any query error.
+func privatizedResponses(responses []datasetAlleleResponse, exists bool) []datasetAlleleResponse {
+	sanitized := make([]datasetAlleleResponse, len(responses))
+	for i, response := range responses {
+		sanitized[i] = datasetAlleleResponse{DatasetID: response.DatasetID, Exists: exists, Error: response.Error}
+	}
+	return sanitized
+}
+
+type forwardOrigin struct {
+	handler func(w http.ResponseWriter, req *http.Request)
+	methods []string
+}
+
+func (f *forwardOrigin) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if origin := req.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if req.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(f.methods, ","))
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+			return
+		}
+	}
+	f.handler(w, req)
 }
 
 func parseInput(r *http.Request) (*variants.Query, error) {
@@ -115,20 +300,26 @@ func parseInput(r *http.Request) (*variants.Query, error) {
 		var query variants.Query
 		query.RefName = r.FormValue("chromosome")
 		query.Allele = r.FormValue("allele")
+		query.AlternateBases = r.FormValue("alternateBases")
+		query.AssemblyID = r.FormValue("assemblyId")
+		query.VariantType = variants.VariantType(r.FormValue("variantType"))
 		if err := parseFormCoordinates(r, &query); err != nil {
 			return nil, fmt.Errorf("parsing referenceBases: %v", err)
 		}
 		return &query, nil
 	case "POST":
 		var params struct {
-			RefName  string `json:"chromosome"`
-			Allele   string `json:"allele"`
-			Start    *int64 `json:"start"`
-			End      *int64 `json:"end"`
-			StartMin *int64 `json:"startMin"`
-			StartMax *int64 `json:"startMax"`
-			EndMin   *int64 `json:"endMin"`
-			EndMax   *int64 `json:"endMax"`
+			RefName        string `json:"chromosome"`
+			Allele         string `json:"allele"`
+			AlternateBases string `json:"alternateBases"`
+			AssemblyID     string `json:"assemblyId"`
+			VariantType    string `json:"variantType"`
+			Start          *int64 `json:"start"`
+			End            *int64 `json:"end"`
+			StartMin       *int64 `json:"startMin"`
+			StartMax       *int64 `json:"startMax"`
+			EndMin         *int64 `json:"endMin"`
+			EndMax         *int64 `json:"endMax"`
 		}
 		body, _ := ioutil.ReadAll(r.Body)
 		if err := json.Unmarshal(body, &params); err != nil {
@@ -136,14 +327,17 @@ func parseInput(r *http.Request) (*variants.Query, error) {
 		}
 
 		return &variants.Query{
-			RefName:  params.RefName,
-			Allele:   params.Allele,
-			Start:    params.Start,
-			End:      params.End,
-			StartMin: params.StartMin,
-			StartMax: params.StartMax,
-			EndMin:   params.EndMin,
-			EndMax:   params.EndMax,
+			RefName:        params.RefName,
+			Allele:         params.Allele,
+			AlternateBases: params.AlternateBases,
+			AssemblyID:     params.AssemblyID,
+			VariantType:    variants.VariantType(params.VariantType),
+			Start:          params.Start,
+			End:            params.End,
+			StartMin:       params.StartMin,
+			StartMax:       params.StartMax,
+			EndMin:         params.EndMin,
+			EndMax:         params.EndMax,
 		}, nil
 	default:
 		return nil, errors.New(fmt.Sprintf("HTTP method %s not supported", r.Method))
@@ -182,65 +376,98 @@ func getFormValueInt(r *http.Request, key string) (*int64, error) {
 	return &value, nil
 }
 
-func writeResponse(w http.ResponseWriter, exists bool) {
-	type beaconResponse struct {
-		XMLName struct{} `xml:"BEACONResponse"`
-		Exists  bool     `xml:"exists"`
+// prefersXML reports whether the request's Accept header names the legacy XML representation
+// as its single most preferred media type. JSON is the default representation, so ordinary
+// browser Accept headers (which list application/xml alongside text/html or */* at a lower or
+// equal q-value) must not trigger XML; only a client that asks for application/xml above
+// everything else, e.g. "Accept: application/xml", gets it.
+func prefersXML(r *http.Request) bool {
+	best, bestQ, tied := "", -1.0, false
+	for _, entry := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, q := parseAcceptEntry(entry)
+		if mediaType == "" || q <= 0 {
+			continue
+		}
+		if q > bestQ {
+			best, bestQ, tied = mediaType, q, false
+		} else if q == bestQ && mediaType != best {
+			tied = true
+		}
 	}
-	var resp beaconResponse
-	resp.Exists = exists
-
-	w.Header().Set("Content-Type", "application/xml")
-	enc := xml.NewEncoder(w)
-	enc.Indent("", "  ")
-	enc.Encode(resp)
+	return best == "application/xml" && !tied
 }
 
-type forwardOrigin struct {
-	handler func(w http.ResponseWriter, req *http.Request)
-	methods []string
-}
-
-func (f *forwardOrigin) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	if origin := req.Header.Get("Origin"); origin != "" {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		if req.Method == "OPTIONS" {
-			w.Header().Set("Access-Control-Allow-Methods", strings.Join(f.methods, ","))
-			w.Header().Set("Access-Control-Allow-Headers", "Authorization")
-			return
+// parseAcceptEntry parses a single comma-separated entry of an Accept header (a media type
+// optionally followed by ";q=..." and other parameters), returning the bare media type and its
+// q-value (defaulting to 1 if absent or malformed).
+func parseAcceptEntry(entry string) (mediaType string, q float64) {
+	parts := strings.Split(entry, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(parts[0]))
+	if mediaType == "" {
+		return "", 0
+	}
+	q = 1.0
+	for _, param := range parts[1:] {
+		name, value, found := strings.Cut(param, "=")
+		if !found || strings.TrimSpace(name) != "q" {
+			continue
+		}
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			q = parsed
 		}
 	}
-	f.handler(w, req)
+	return mediaType, q
 }
 
-func (api *Server) newBQClient(req *http.Request, projectID string) (*bigquery.Client, error) {
-	switch api.AuthMode {
-	case ServiceAuth:
-		return bigquery.NewClient(appengine.NewContext(req), projectID)
-	case UserAuth:
-		return newClientFromBearerToken(req.WithContext(appengine.NewContext(req)), projectID)
-	default:
-		return nil, fmt.Errorf("invalid value %d for server authentication mode", api.AuthMode)
+// clientIdentifier identifies the caller for query-budget tracking, combining their address with
+// their Authorization header value (if any) so that per-client budgets aren't shared across an
+// untrusted shared IP.
+func clientIdentifier(r *http.Request) string {
+	id := clientHost(r)
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		id += "|" + auth
 	}
+	return id
 }
 
-func newClientFromBearerToken(req *http.Request, projectID string) (*bigquery.Client, error) {
-	authorization := req.Header.Get("Authorization")
-
-	fields := strings.Split(authorization, " ")
-	if len(fields) != 2 || fields[0] != "Bearer" {
-		return nil, errors.New("missing or invalid authentication token")
+// clientHost returns the caller's IP address, without the ephemeral port that RemoteAddr carries
+// and that would otherwise give every new connection its own query budget. It trusts only
+// headers the frontend itself sets, never a client-supplied one: on App Engine, RemoteAddr is
+// the front end, which reports the true caller in X-AppEngine-User-IP; failing that, it takes
+// the rightmost (i.e. nearest, and so least spoofable) hop of X-Forwarded-For. The leftmost hop
+// is never used, since a client can set that to an arbitrary value per request.
+func clientHost(r *http.Request) string {
+	if ip := r.Header.Get("X-AppEngine-User-IP"); ip != "" {
+		return ip
 	}
-
-	token := oauth2.Token{
-		TokenType:   fields[0],
-		AccessToken: fields[1],
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		if host := strings.TrimSpace(hops[len(hops)-1]); host != "" {
+			return host
+		}
 	}
-
-	client, err := bigquery.NewClient(req.Context(), projectID, option.WithTokenSource(oauth2.StaticTokenSource(&token)))
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return nil, fmt.Errorf("creating bigquery client: %v", err)
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeLegacyXMLResponse(w http.ResponseWriter, exists bool) {
+	type beaconResponse struct {
+		XMLName struct{} `xml:"BEACONResponse"`
+		Exists  bool     `xml:"exists"`
 	}
+	var resp beaconResponse
+	resp.Exists = exists
 
-	return client, nil
+	w.Header().Set("Content-Type", "application/xml")
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(resp)
 }
@@ -0,0 +1,193 @@
+// Package privacy implements an optional differential-privacy layer for Beacon allele queries,
+// to resist membership-inference attacks (e.g. the Shringarpure-Bustamante attack) that
+// re-identify individuals by repeatedly probing a beacon for alleles known to be rare in a
+// targeted sample.
+package privacy
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned by Config.Apply when a client has no query budget remaining.
+var ErrBudgetExhausted = errors.New("query budget exhausted")
+
+// Config controls the differential-privacy layer. The zero Config (Budget == 0) disables it.
+type Config struct {
+	// Epsilon is the total privacy loss a client may incur by exhausting their query budget:
+	// smaller values leak less per query but randomize the response more aggressively.
+	Epsilon float64
+	// Budget is the number of queries each client is allotted before their responses are
+	// suppressed.
+	Budget int
+	// Store tracks each client's remaining query budget. Required when Budget > 0.
+	Store Store
+	// Clock supplies the current time for logging privatized decisions. Required when Budget > 0.
+	Clock Clock
+	// Rand supplies randomness for the privacy coin flip. Required when Budget > 0.
+	Rand Rand
+	// RejectExhausted, if true, reports budget exhaustion to the caller as ErrBudgetExhausted
+	// (callers typically translate this to an HTTP 429) instead of silently returning false.
+	RejectExhausted bool
+	// Logger receives one line per privatized decision, identifying the client and the
+	// (possibly flipped) response, but never the true answer. os.Stderr is used if nil.
+	Logger Logger
+}
+
+// Enabled reports whether the privacy layer is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && c.Budget > 0
+}
+
+// flipProbability is the probability with which Apply flips the true answer, calibrated so that
+// a client who spends their entire Budget incurs at most Epsilon privacy loss (the standard
+// randomized-response mechanism for (epsilon,0)-differential privacy).
+func (c *Config) flipProbability() float64 {
+	return 1 / (1 + math.Exp(c.Epsilon/float64(c.Budget)))
+}
+
+// Apply consumes one unit of clientID's query budget and returns the response to report for
+// exists: the true value, randomized with calibrated probability, if budget remains; or
+// ErrBudgetExhausted if it does not and RejectExhausted is set (callers that don't set
+// RejectExhausted get a fixed false instead, with no error). It is safe for concurrent use.
+func (c *Config) Apply(clientID string, exists bool) (bool, error) {
+	if !c.Store.Take(clientID, c.Budget) {
+		if c.RejectExhausted {
+			return false, ErrBudgetExhausted
+		}
+		c.logger().Log(c.Clock.Now(), clientID, false)
+		return false, nil
+	}
+
+	flip, err := c.Rand.Bool(c.flipProbability())
+	if err != nil {
+		return false, fmt.Errorf("generating privacy coin flip: %v", err)
+	}
+	privatized := exists != flip
+
+	c.logger().Log(c.Clock.Now(), clientID, privatized)
+	return privatized, nil
+}
+
+func (c *Config) logger() Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return StderrLogger{}
+}
+
+// Clock supplies the current time. It is an interface so tests can supply a fixed clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock backed by time.Now.
+type SystemClock struct{}
+
+// Now implements Clock.
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// Rand supplies randomness for the privacy mechanism's coin flips.
+type Rand interface {
+	// Bool returns true with probability p.
+	Bool(p float64) (bool, error)
+}
+
+// SecureRand is a Rand backed by crypto/rand, suitable for production use.
+type SecureRand struct{}
+
+// Bool implements Rand.
+func (SecureRand) Bool(p float64) (bool, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return false, fmt.Errorf("reading random bytes: %v", err)
+	}
+	draw := float64(binary.LittleEndian.Uint64(buf[:])) / float64(math.MaxUint64)
+	return draw < p, nil
+}
+
+// Logger records privatized decisions, one per query, without ever recording the true answer.
+type Logger interface {
+	Log(at time.Time, clientID string, privatizedExists bool)
+}
+
+// StderrLogger is a Logger that writes to stderr via the standard log package.
+type StderrLogger struct{}
+
+// Log implements Logger.
+func (StderrLogger) Log(at time.Time, clientID string, privatizedExists bool) {
+	log.Printf("privacy: %s client=%q exists=%v\n", at.Format(time.RFC3339), clientID, privatizedExists)
+}
+
+// Store tracks each client's remaining query budget and must be safe for concurrent use.
+type Store interface {
+	// Take atomically consumes one unit of clientID's budget, initializing it to initialBudget
+	// on first use, and reports whether any budget remained before the decrement.
+	Take(clientID string, initialBudget int) bool
+}
+
+// MemoryStore is a Store backed by an in-process, capacity-bounded LRU cache. It is intended for
+// single-instance deployments; multi-instance deployments should supply a Store backed by a
+// shared store instead (e.g. Redis or Datastore).
+type MemoryStore struct {
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used client at the front
+}
+
+type memoryStoreEntry struct {
+	clientID  string
+	remaining int
+}
+
+// NewMemoryStore returns a MemoryStore that tracks at most capacity clients, evicting the least
+// recently used client once that capacity is exceeded.
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(clientID string, initialBudget int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[clientID]
+	if !ok {
+		elem = s.order.PushFront(&memoryStoreEntry{clientID: clientID, remaining: initialBudget})
+		s.entries[clientID] = elem
+		s.evictLocked()
+	} else {
+		s.order.MoveToFront(elem)
+	}
+
+	entry := elem.Value.(*memoryStoreEntry)
+	if entry.remaining <= 0 {
+		return false
+	}
+	entry.remaining--
+	return true
+}
+
+func (s *MemoryStore) evictLocked() {
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		if oldest == nil {
+			return
+		}
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryStoreEntry).clientID)
+	}
+}
@@ -0,0 +1,117 @@
+package privacy
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fixedClock struct{ t time.Time }
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+type nullLogger struct{}
+
+func (nullLogger) Log(time.Time, string, bool) {}
+
+func newTestConfig(epsilon float64, budget int) *Config {
+	return &Config{
+		Epsilon: epsilon,
+		Budget:  budget,
+		Store:   NewMemoryStore(1000),
+		Clock:   fixedClock{},
+		Rand:    SecureRand{},
+		Logger:  nullLogger{},
+	}
+}
+
+func TestApplyEmpiricalFlipRateMatchesProbability(t *testing.T) {
+	const epsilon = 1.0
+	const budget = 100000
+	c := newTestConfig(epsilon, budget)
+	want := c.flipProbability()
+
+	var flips int
+	for i := 0; i < budget; i++ {
+		got, err := c.Apply("client", true)
+		if err != nil {
+			t.Fatalf("Apply() returned error: %v", err)
+		}
+		if !got {
+			flips++
+		}
+	}
+
+	got := float64(flips) / float64(budget)
+	if diff := math.Abs(got - want); diff > 0.01 {
+		t.Errorf("empirical flip rate = %v, want within 0.01 of calibrated probability %v", got, want)
+	}
+}
+
+func TestApplyExhaustsBudget(t *testing.T) {
+	c := newTestConfig(1.0, 3)
+	for i := 0; i < 3; i++ {
+		if _, err := c.Apply("client", true); err != nil {
+			t.Fatalf("Apply() call %d returned error: %v", i, err)
+		}
+	}
+
+	got, err := c.Apply("client", true)
+	if err != nil {
+		t.Fatalf("Apply() after budget exhaustion returned error: %v", err)
+	}
+	if got {
+		t.Errorf("Apply() after budget exhaustion = %v, want false", got)
+	}
+}
+
+func TestApplyRejectsExhaustedWhenConfigured(t *testing.T) {
+	c := newTestConfig(1.0, 1)
+	c.RejectExhausted = true
+
+	if _, err := c.Apply("client", true); err != nil {
+		t.Fatalf("Apply() for the first query returned error: %v", err)
+	}
+	if _, err := c.Apply("client", true); err != ErrBudgetExhausted {
+		t.Errorf("Apply() after budget exhaustion returned %v, want ErrBudgetExhausted", err)
+	}
+}
+
+func TestMemoryStoreTakeIsRaceFree(t *testing.T) {
+	store := NewMemoryStore(10)
+	const budget = 1000
+	const workers = 50
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var granted int
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < budget/workers+1; j++ {
+				if store.Take("client", budget) {
+					mu.Lock()
+					granted++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if granted != budget {
+		t.Errorf("concurrent Take() granted %d units, want exactly %d", granted, budget)
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryStore(1)
+	store.Take("a", 5)
+	store.Take("b", 5) // evicts "a"
+
+	if !store.Take("a", 5) {
+		t.Error("Take() for evicted client \"a\" failed, want a fresh budget")
+	}
+}
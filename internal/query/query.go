@@ -21,20 +21,24 @@ type Query struct {
 
 // Execute queries the allele database with the Query parameters.
 func (q *Query) Execute(ctx context.Context, projectID, tableID string) (bool, error) {
-	query := fmt.Sprintf(`
+	where, params := q.whereClause()
+	sql := fmt.Sprintf(`
 		SELECT count(v.reference_name) as count
 		FROM %s as v
 		WHERE %s
 		LIMIT 1`,
 		fmt.Sprintf("`%s`", tableID),
-		q.whereClause(),
+		where,
 	)
 
 	client, err := bigquery.NewClient(ctx, projectID)
 	if err != nil {
 		return false, fmt.Errorf("creating bigquery client: %v", err)
 	}
-	it, err := client.Query(query).Read(ctx)
+	bqQuery := client.Query(sql)
+	bqQuery.Parameters = params
+
+	it, err := bqQuery.Read(ctx)
 	if err != nil {
 		return false, fmt.Errorf("querying database: %v", err)
 	}
@@ -62,21 +66,30 @@ func (q *Query) ValidateInput() error {
 	return nil
 }
 
-func (q *Query) whereClause() string {
+// whereClause builds a parameterized WHERE clause for the query, returning the
+// clause text (referencing named parameters) alongside the bound parameter
+// values. Callers must attach the returned parameters to the bigquery.Query
+// before executing it; values are never interpolated directly into the SQL.
+func (q *Query) whereClause() (string, []bigquery.QueryParameter) {
 	var clauses []string
-	add := func(format string, args ...interface{}) {
-		clauses = append(clauses, fmt.Sprintf(format, args...))
+	var params []bigquery.QueryParameter
+	add := func(clause string) {
+		clauses = append(clauses, clause)
+	}
+	param := func(name string, value interface{}) string {
+		params = append(params, bigquery.QueryParameter{Name: name, Value: value})
+		return "@" + name
 	}
-	simpleClause := func(dbColumn, value string) {
+	simpleClause := func(dbColumn, paramName, value string) {
 		if dbColumn != "" && value != "" {
-			add("%s='%s'", dbColumn, value)
+			add(fmt.Sprintf("%s=%s", dbColumn, param(paramName, value)))
 		}
 	}
-	simpleClause("reference_name", q.RefName)
-	simpleClause("reference_bases", q.Allele)
+	simpleClause("reference_name", "refName", q.RefName)
+	simpleClause("reference_bases", "allele", q.Allele)
 	// Start is inclusive, End is exclusive.  Search exactly for coordinate.
 	if q.Coord != nil {
-		add("v.start <= %d AND %d < v.end", *q.Coord, *q.Coord+1)
+		add(fmt.Sprintf("v.start <= %s AND %s < v.end", param("coord", *q.Coord), param("coordEnd", *q.Coord+1)))
 	}
-	return strings.Join(clauses, " AND ")
+	return strings.Join(clauses, " AND "), params
 }
@@ -0,0 +1,51 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWhereClauseParameterizesUserInput(t *testing.T) {
+	pathological := []string{
+		`'; DROP TABLE variants; --`,
+		`x' OR '1'='1`,
+		`A' UNION SELECT * FROM secrets -- `,
+	}
+	for _, value := range pathological {
+		q := &Query{RefName: value, Allele: value}
+		clause, params := q.whereClause()
+
+		if strings.Contains(clause, value) {
+			t.Errorf("whereClause() with RefName/Allele %q produced clause %q; want the raw value kept out of the SQL text", value, clause)
+		}
+		if len(params) != 2 {
+			t.Fatalf("whereClause() with RefName/Allele %q produced %d parameters, want 2", value, len(params))
+		}
+		for _, p := range params {
+			if p.Value != value {
+				t.Errorf("parameter %s = %v, want %q", p.Name, p.Value, value)
+			}
+		}
+	}
+}
+
+func TestWhereClauseCoordinateParameters(t *testing.T) {
+	coord := int64(42)
+	q := &Query{RefName: "chr1", Allele: "A", Coord: &coord}
+	clause, params := q.whereClause()
+
+	if strings.Contains(clause, "42") {
+		t.Errorf("whereClause() = %q, want coordinate bound as a parameter, not interpolated", clause)
+	}
+
+	values := map[string]interface{}{}
+	for _, p := range params {
+		values[p.Name] = p.Value
+	}
+	if values["coord"] != coord {
+		t.Errorf("coord parameter = %v, want %d", values["coord"], coord)
+	}
+	if values["coordEnd"] != coord+1 {
+		t.Errorf("coordEnd parameter = %v, want %d", values["coordEnd"], coord+1)
+	}
+}
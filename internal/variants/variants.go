@@ -16,15 +16,26 @@
 package variants
 
 import (
-	"context"
 	"errors"
 	"fmt"
-	"strings"
+)
+
+// VariantType identifies the class of structural variant a Query should be restricted to.
+type VariantType string
 
-	"cloud.google.com/go/bigquery"
+// The variant types recognized by Query.VariantType.
+const (
+	SNP               VariantType = "SNP"
+	Insertion         VariantType = "INS"
+	Deletion          VariantType = "DEL"
+	Duplication       VariantType = "DUP"
+	CopyNumberVariant VariantType = "CNV"
+	Breakend          VariantType = "BND"
 )
 
-// Query holds information about a single query against a Beacon.
+// Query holds information about a single query against a Beacon. It is storage-agnostic: a
+// backend.Backend is responsible for turning a Query into whatever lookup its storage engine
+// requires.
 type Query struct {
 	// RefName is the chromosome reference name.
 	RefName string
@@ -42,30 +53,26 @@ type Query struct {
 	EndMin *int64
 	// EndMax matches the alleles that end at this position or lower.
 	EndMax *int64
+	// VariantType restricts matches to a specific class of structural variant. Leave empty to
+	// match any variant type.
+	VariantType VariantType
+	// AlternateBases is the alternate allele bases to match.
+	AlternateBases string
+	// AssemblyID identifies the reference genome assembly the coordinates are expressed against.
+	AssemblyID string
 }
 
-// Execute queries the allele database with the Query parameters.
-func (q *Query) Execute(ctx context.Context, client *bigquery.Client, tableID string) (bool, error) {
-	query := fmt.Sprintf(`
-		SELECT count(v.reference_name) as count
-		FROM %s as v
-		WHERE %s`,
-		fmt.Sprintf("`%s`", tableID),
-		q.whereClause(),
-	)
-
-	it, err := client.Query(query).Read(ctx)
-	if err != nil {
-		return false, fmt.Errorf("querying database: %v", err)
-	}
-
-	var result struct {
-		Count int
-	}
-	if err := it.Next(&result); err != nil {
-		return false, fmt.Errorf("reading query result: %v", err)
-	}
-	return result.Count > 0, nil
+// Result holds the aggregate variant statistics matched by a Query against a dataset.
+type Result struct {
+	// VariantCount is the number of variants matching the query.
+	VariantCount int64
+	// CallCount is the total number of genotype calls across matching variants.
+	CallCount int64
+	// SampleCount is the total number of samples across matching variants.
+	SampleCount int64
+	// Frequency is the allele frequency across matching variants, i.e.
+	// SUM(allele_count) / SUM(allele_number).
+	Frequency float64
 }
 
 // ValidateInput validates the Query parameters meet the ga4gh beacon api requirements.
@@ -73,8 +80,10 @@ func (q *Query) ValidateInput() error {
 	if q.RefName == "" {
 		return errors.New("missing chromosome name")
 	}
-	if q.Allele == "" {
-		return errors.New("missing allele")
+	// A specific allele is required unless this is a structural-variant query, which is
+	// identified by VariantType rather than an exact reference/alternate base match.
+	if q.Allele == "" && q.VariantType == "" {
+		return errors.New("missing allele or variantType")
 	}
 	if err := q.validateCoordinates(); err != nil {
 		return fmt.Errorf("validating coordinates: %v", err)
@@ -93,39 +102,63 @@ func (q *Query) validateCoordinates() error {
 	return errors.New("an unusable combination of coordinate parameters was specified")
 }
 
-func (q *Query) whereClause() string {
-	var clauses []string
-	add := func(format string, args ...interface{}) {
-		clauses = append(clauses, fmt.Sprintf(format, args...))
+// MatchesVariant reports whether a variant with the given reference name, reference/alternate
+// bases, and half-open [start, end) coordinates satisfies q. Backends that cannot push filtering
+// down into their storage engine (e.g. backend/vcf, which scans records directly) use this to
+// filter in process; backend/bigquery instead expresses the same semantics as a SQL predicate.
+func (q *Query) MatchesVariant(refName, referenceBases, alternateBases string, start, end int64) bool {
+	if q.RefName != "" && q.RefName != refName {
+		return false
 	}
-	simpleClause := func(dbColumn, value interface{}) {
-		switch value := value.(type) {
-		case string:
-			if value != "" {
-				add("%s='%s'", dbColumn, value)
-			}
-		case *int64:
-			if value != nil {
-				add("%s=%d", dbColumn, value)
-			}
-		}
+	if q.Allele != "" && q.Allele != referenceBases {
+		return false
 	}
-	simpleClause("reference_name", q.RefName)
-	simpleClause("reference_bases", q.Allele)
-	simpleClause("start_position", q.Start)
-	simpleClause("end_position", q.End)
-
-	if q.StartMin != nil {
-		add("%d <= v.start_position", q.StartMin)
+	if q.AlternateBases != "" && q.AlternateBases != alternateBases {
+		return false
 	}
-	if q.StartMax != nil {
-		add("%v.start_position <= %d", q.StartMax)
+	if q.Start != nil && *q.Start != start {
+		return false
 	}
-	if q.EndMin != nil {
-		add("%d <= v.end_position", q.EndMin)
+	if q.End != nil && *q.End != end {
+		return false
 	}
-	if q.EndMax != nil {
-		add("v.end_position <= %d", q.StartMax)
+	if q.StartMin != nil && start < *q.StartMin {
+		return false
+	}
+	if q.StartMax != nil && start > *q.StartMax {
+		return false
+	}
+	if q.EndMin != nil && end < *q.EndMin {
+		return false
+	}
+	if q.EndMax != nil && end > *q.EndMax {
+		return false
+	}
+
+	// The variant types below have no explicit column to compare against, so they are
+	// recognized with the same heuristics backend/bigquery pushes down to SQL: a SNP has
+	// equal-length alleles, an insertion/deletion is recognized by the direction of the length
+	// mismatch, and the remaining structural types are recognized by their symbolic ALT
+	// notation (e.g. "<DUP>") together with the same length mismatch.
+	switch q.VariantType {
+	case "":
+	case SNP:
+		if len(referenceBases) != len(alternateBases) {
+			return false
+		}
+	case Insertion:
+		if len(alternateBases) <= len(referenceBases) {
+			return false
+		}
+	case Deletion:
+		if len(alternateBases) >= len(referenceBases) {
+			return false
+		}
+	case Duplication, CopyNumberVariant, Breakend:
+		symbol := "<" + string(q.VariantType)
+		if len(alternateBases) < len(symbol) || alternateBases[:len(symbol)] != symbol || len(referenceBases) == len(alternateBases) {
+			return false
+		}
 	}
-	return strings.Join(clauses, " AND ")
+	return true
 }
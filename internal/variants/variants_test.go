@@ -0,0 +1,81 @@
+package variants
+
+import "testing"
+
+func TestValidateInputAllowsStructuralVariantRangeQueries(t *testing.T) {
+	startMin, startMax := int64(100), int64(200)
+	endMin, endMax := int64(300), int64(400)
+	q := &Query{
+		RefName:     "chr1",
+		VariantType: Deletion,
+		StartMin:    &startMin,
+		StartMax:    &startMax,
+		EndMin:      &endMin,
+		EndMax:      &endMax,
+	}
+	if err := q.ValidateInput(); err != nil {
+		t.Errorf("ValidateInput() = %v, want a range+variantType query with no Allele to validate", err)
+	}
+}
+
+func TestValidateInputRequiresAlleleOrVariantType(t *testing.T) {
+	start := int64(100)
+	q := &Query{RefName: "chr1", Start: &start}
+	if err := q.ValidateInput(); err == nil {
+		t.Errorf("ValidateInput() = nil, want an error when neither Allele nor VariantType is set")
+	}
+}
+
+func TestMatchesVariantBasics(t *testing.T) {
+	q := &Query{RefName: "chr1", Allele: "A"}
+	if !q.MatchesVariant("chr1", "A", "T", 100, 101) {
+		t.Errorf("MatchesVariant() = false, want true for a matching reference name and allele")
+	}
+	if q.MatchesVariant("chr2", "A", "T", 100, 101) {
+		t.Errorf("MatchesVariant() = true, want false for a mismatched reference name")
+	}
+	if q.MatchesVariant("chr1", "C", "T", 100, 101) {
+		t.Errorf("MatchesVariant() = true, want false for a mismatched allele")
+	}
+}
+
+func TestMatchesVariantRangeBounds(t *testing.T) {
+	startMin, startMax := int64(100), int64(200)
+	endMin, endMax := int64(300), int64(400)
+	q := &Query{RefName: "chr1", StartMin: &startMin, StartMax: &startMax, EndMin: &endMin, EndMax: &endMax}
+
+	if !q.MatchesVariant("chr1", "A", "T", 150, 350) {
+		t.Errorf("MatchesVariant() = false, want true for a variant inside the queried range")
+	}
+	if q.MatchesVariant("chr1", "A", "T", 50, 350) {
+		t.Errorf("MatchesVariant() = true, want false when start is below StartMin")
+	}
+	if q.MatchesVariant("chr1", "A", "T", 150, 450) {
+		t.Errorf("MatchesVariant() = true, want false when end is above EndMax")
+	}
+}
+
+func TestMatchesVariantTypeHeuristics(t *testing.T) {
+	tests := []struct {
+		variantType    VariantType
+		referenceBases string
+		alternateBases string
+		want           bool
+	}{
+		{SNP, "A", "T", true},
+		{SNP, "A", "TT", false},
+		{Insertion, "A", "ATT", true},
+		{Insertion, "ATT", "A", false},
+		{Deletion, "ATT", "A", true},
+		{Deletion, "A", "ATT", false},
+		{Duplication, "A", "<DUP>", true},
+		{Duplication, "A", "T", false},
+	}
+	for _, test := range tests {
+		q := &Query{RefName: "chr1", VariantType: test.variantType}
+		if got := q.MatchesVariant("chr1", test.referenceBases, test.alternateBases, 0, 1); got != test.want {
+			t.Errorf("MatchesVariant() with VariantType %s, ref %q, alt %q = %v, want %v",
+				test.variantType, test.referenceBases, test.alternateBases, got, test.want)
+		}
+	}
+}